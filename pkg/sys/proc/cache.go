@@ -0,0 +1,282 @@
+package proc
+
+import (
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+)
+
+// DefaultCacheSize is the number of process entries kept by a Cache created
+// without an explicit size.
+const DefaultCacheSize = 8192
+
+// cacheEntry holds everything about a process that is expensive to
+// re-derive from /proc: its parsed stat fields, cgroup membership,
+// container identity, and command line. It is looked up by PID, since
+// that's how every caller addresses a process, but carries the process's
+// UniqueID once known so it can be cross-referenced independently of PID
+// reuse.
+//
+// pid and uniqueID are only ever touched under the owning Cache's mu (they
+// exist to key Cache's own indexes). Every other field may be read or
+// written by multiple goroutines that looked the same entry up
+// concurrently, so they're guarded by mu instead.
+type cacheEntry struct {
+	pid      int32
+	uniqueID string
+
+	mu               sync.Mutex
+	ps               *ProcessStatus
+	cgroups          []Cgroup
+	cgroupsKnown     bool
+	containerID      string
+	containerRuntime string
+	containerKnown   bool
+	cmdline          []string
+	cmdlineKnown     bool
+}
+
+// Cache is an in-memory, LRU-bounded table of process information keyed by
+// PID. It exists to eliminate the dominant cost of heavy syscall tracing:
+// repeatedly re-reading and re-parsing /proc/[pid]/stat and
+// /proc/[pid]/cgroup for the same process on every event. FileSystem
+// methods consult a Cache (when one is configured) before falling back to
+// /proc, and populate it on miss.
+//
+// A Cache is safe for concurrent use.
+type Cache struct {
+	mu         sync.Mutex
+	capacity   int
+	ll         *list.List
+	byPID      map[int32]*list.Element
+	byUniqueID map[string]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+// NewCache creates a Cache that holds at most maxEntries processes,
+// evicting the least recently used entry once full. A maxEntries of 0
+// selects DefaultCacheSize.
+func NewCache(maxEntries int) *Cache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheSize
+	}
+
+	return &Cache{
+		capacity:   maxEntries,
+		ll:         list.New(),
+		byPID:      make(map[int32]*list.Element),
+		byUniqueID: make(map[string]*list.Element),
+	}
+}
+
+// lookup returns the cache entry for pid without disturbing hit/miss
+// counters, marking it as recently used if found. Callers record a hit or
+// miss themselves once they know whether the specific field they wanted
+// was actually populated.
+func (c *Cache) lookup(pid int32) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.byPID[pid]
+	if !ok {
+		return nil
+	}
+
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*cacheEntry)
+}
+
+// recordHit and recordMiss update the cache's hit/miss counters.
+func (c *Cache) recordHit() {
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+}
+
+func (c *Cache) recordMiss() {
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+}
+
+// entry returns the cache entry for pid, creating an empty one (without
+// counting a hit or a miss) if none exists yet.
+func (c *Cache) entry(pid int32) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.byPID[pid]; ok {
+		c.ll.MoveToFront(elem)
+		return elem.Value.(*cacheEntry)
+	}
+
+	e := &cacheEntry{pid: pid}
+	elem := c.ll.PushFront(e)
+	c.byPID[pid] = elem
+
+	if c.ll.Len() > c.capacity {
+		c.evictOldest()
+	}
+
+	return e
+}
+
+// indexUniqueID records e's UniqueID in the secondary index, now that it's
+// known.
+func (c *Cache) indexUniqueID(e *cacheEntry, uniqueID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e.uniqueID == uniqueID {
+		return
+	}
+	if e.uniqueID != "" {
+		delete(c.byUniqueID, e.uniqueID)
+	}
+	e.uniqueID = uniqueID
+	if elem, ok := c.byPID[e.pid]; ok {
+		c.byUniqueID[uniqueID] = elem
+	}
+}
+
+func (c *Cache) evictOldest() {
+	elem := c.ll.Back()
+	if elem == nil {
+		return
+	}
+	c.removeElement(elem)
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	e := elem.Value.(*cacheEntry)
+	delete(c.byPID, e.pid)
+	if e.uniqueID != "" {
+		delete(c.byUniqueID, e.uniqueID)
+	}
+}
+
+// Invalidate removes any cached information about pid. Sensors should call
+// this when they observe a fork, exec, or exit perf event for pid, since
+// each of those can change (or end) what /proc has to say about it.
+func (c *Cache) Invalidate(pid int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.byPID[pid]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// ScanForReuse looks for cached entries whose process start time no longer
+// matches what /proc reports for that PID, which means the PID has been
+// recycled for a different process, and evicts them. Callers that hold
+// processes in a Cache for a long time should run this periodically (e.g.
+// from a background goroutine) to bound the staleness of PID-keyed data.
+func (c *Cache) ScanForReuse(fs *FileSystem) {
+	c.mu.Lock()
+	pids := make([]int32, 0, len(c.byPID))
+	for pid := range c.byPID {
+		pids = append(pids, pid)
+	}
+	c.mu.Unlock()
+
+	for _, pid := range pids {
+		stat, err := fs.ReadFile(fmt.Sprintf("%d/stat", pid))
+		if err != nil {
+			// The process is gone; its cache entry is stale either way.
+			c.Invalidate(pid)
+			continue
+		}
+
+		fields, err := parseStatLine(string(stat))
+		if err != nil {
+			continue
+		}
+		fresh := &ProcessStatus{statFields: fields, pid: pid}
+
+		c.mu.Lock()
+		elem, ok := c.byPID[pid]
+		var cachedPS *ProcessStatus
+		if ok {
+			cached := elem.Value.(*cacheEntry)
+			cached.mu.Lock()
+			cachedPS = cached.ps
+			cached.mu.Unlock()
+		}
+		if cachedPS != nil {
+			cachedStart, cachedErr := cachedPS.StartTime()
+			freshStart, freshErr := fresh.StartTime()
+			if cachedErr == nil && freshErr == nil && cachedStart != freshStart {
+				c.removeElement(elem)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Snapshot walks /proc once and prefills the cache with every process
+// currently running, so that the first event for a long-lived process
+// doesn't pay the cost of a cold cache.
+func (c *Cache) Snapshot(fs *FileSystem) error {
+	names, err := readProcPIDs(fs)
+	if err != nil {
+		return err
+	}
+
+	if fs.cache != c {
+		fs = &FileSystem{MountPoint: fs.MountPoint, nsPID: fs.nsPID, cache: c}
+	}
+
+	for _, pid := range names {
+		fs.Stat(pid)
+		fs.Cgroups(pid)
+		fs.ContainerID(pid)
+	}
+
+	return nil
+}
+
+// Stats returns the number of cache hits and misses observed so far.
+func (c *Cache) Stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Len returns the number of processes currently held in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// readProcPIDs lists the PIDs of every process currently visible through fs
+// by reading the numeric entries of its procfs mountpoint directly (so it
+// doesn't recurse back into the cache it's being used to prefill).
+func readProcPIDs(fs *FileSystem) ([]int32, error) {
+	entries, err := ioutil.ReadDir(fs.MountPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int32
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		i, err := strconv.ParseInt(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		pids = append(pids, int32(i))
+	}
+
+	return pids, nil
+}
@@ -0,0 +1,39 @@
+package proc
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+// TestCacheConcurrentAccess exercises Stat, CommandLine, and Cgroups for the
+// same PID from many goroutines at once through a shared Cache. It's meant
+// to be run with -race: before cacheEntry and ProcessStatus had their own
+// locking, this reliably tripped the race detector on e.cmdline/e.ps and on
+// ProcessStatus's lazily-parsed fields.
+func TestCacheConcurrentAccess(t *testing.T) {
+	fs := &FileSystem{MountPoint: "/proc", cache: NewCache(0)}
+	pid := int32(os.Getpid())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				if _, err := fs.Stat(pid); err != nil {
+					t.Errorf("Stat(%d): %v", pid, err)
+					return
+				}
+				fs.CommandLine(pid)
+				if _, err := fs.Cgroups(pid); err != nil {
+					t.Errorf("Cgroups(%d): %v", pid, err)
+					return
+				}
+				fs.EnrichFromExec(pid, []string{"test"})
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,233 @@
+package proc
+
+import (
+	"regexp"
+	"sync"
+)
+
+// ContainerRuntimeResolver recognizes the cgroup path conventions used by a
+// particular container runtime and extracts the container ID from them.
+type ContainerRuntimeResolver interface {
+	// Match returns the container ID embedded in cgroupPath, and true,
+	// if cgroupPath looks like one of this runtime's cgroup paths.
+	Match(cgroupPath string) (id string, ok bool)
+}
+
+var (
+	containerRuntimesMu sync.RWMutex
+
+	// containerRuntimes is consulted in order, so more specific patterns
+	// should be registered ahead of looser ones.
+	containerRuntimeNames []string
+	containerRuntimes     = map[string]ContainerRuntimeResolver{}
+)
+
+func init() {
+	RegisterContainerRuntime("docker", dockerResolver{})
+	RegisterContainerRuntime("cri-containerd", criContainerdResolver{})
+	RegisterContainerRuntime("crio", crioResolver{})
+	RegisterContainerRuntime("podman", podmanResolver{})
+	RegisterContainerRuntime("lxc", lxcResolver{})
+}
+
+// RegisterContainerRuntime adds (or replaces) a ContainerRuntimeResolver
+// under the given name. Callers may use this to teach ContainerID and
+// ContainerInfo about container runtimes not recognized out of the box.
+func RegisterContainerRuntime(name string, r ContainerRuntimeResolver) {
+	containerRuntimesMu.Lock()
+	defer containerRuntimesMu.Unlock()
+
+	if _, exists := containerRuntimes[name]; !exists {
+		containerRuntimeNames = append(containerRuntimeNames, name)
+	}
+	containerRuntimes[name] = r
+}
+
+// resolveContainer walks the registered ContainerRuntimeResolvers in
+// registration order and returns the ID and runtime name of the first one
+// that recognizes cgroupPath.
+func resolveContainer(cgroupPath string) (id string, runtime string, ok bool) {
+	containerRuntimesMu.RLock()
+	defer containerRuntimesMu.RUnlock()
+
+	for _, name := range containerRuntimeNames {
+		if id, ok := containerRuntimes[name].Match(cgroupPath); ok {
+			return id, name, true
+		}
+	}
+
+	return "", "", false
+}
+
+const hexID = `[0-9a-f]{12,64}`
+
+// dockerResolver matches cgroup paths created by the Docker engine, e.g.
+// "/docker/<id>" or, under a systemd cgroup driver,
+// "/system.slice/docker-<id>.scope".
+type dockerResolver struct{}
+
+var (
+	dockerPathRE  = regexp.MustCompile(`^/docker/(` + hexID + `)`)
+	dockerScopeRE = regexp.MustCompile(`/docker-(` + hexID + `)\.scope$`)
+)
+
+func (dockerResolver) Match(cgroupPath string) (string, bool) {
+	if m := dockerPathRE.FindStringSubmatch(cgroupPath); m != nil {
+		return m[1], true
+	}
+	if m := dockerScopeRE.FindStringSubmatch(cgroupPath); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// criContainerdResolver matches cgroup paths created by containerd's CRI
+// plugin under Kubernetes, e.g.
+// "/kubepods/<qos>/pod<uid>/<id>" or "cri-containerd-<id>.scope".
+type criContainerdResolver struct{}
+
+var (
+	kubepodsPathRE = regexp.MustCompile(`^/kubepods(\.slice)?/.*/(` + hexID + `)(\.scope)?$`)
+	criScopeRE     = regexp.MustCompile(`cri-containerd-(` + hexID + `)\.scope$`)
+)
+
+func (criContainerdResolver) Match(cgroupPath string) (string, bool) {
+	if m := criScopeRE.FindStringSubmatch(cgroupPath); m != nil {
+		return m[1], true
+	}
+	if m := kubepodsPathRE.FindStringSubmatch(cgroupPath); m != nil {
+		return m[2], true
+	}
+	return "", false
+}
+
+// crioResolver matches cgroup paths created by CRI-O's own conmon cgroup
+// layout, e.g. "crio-<id>.scope". CRI-O can also be configured to use the
+// shared libpod conmon layout ("/machine.slice/libpod-<id>.scope"), but
+// that layout is indistinguishable from a genuine Podman container by
+// cgroup path alone, so it's left to podmanResolver rather than guessed
+// at here.
+type crioResolver struct{}
+
+var crioScopeRE = regexp.MustCompile(`crio-(` + hexID + `)\.scope$`)
+
+func (crioResolver) Match(cgroupPath string) (string, bool) {
+	if m := crioScopeRE.FindStringSubmatch(cgroupPath); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// podmanResolver matches cgroup paths created by Podman/libpod, e.g.
+// "/machine.slice/libpod-<id>.scope" or "/libpod_parent/<id>".
+type podmanResolver struct{}
+
+func (podmanResolver) Match(cgroupPath string) (string, bool) {
+	return libpodResolver{}.Match(cgroupPath)
+}
+
+type libpodResolver struct{}
+
+var (
+	libpodScopeRE = regexp.MustCompile(`libpod-(` + hexID + `)\.scope$`)
+	libpodPathRE  = regexp.MustCompile(`^/libpod_parent/(` + hexID + `)`)
+)
+
+func (libpodResolver) Match(cgroupPath string) (string, bool) {
+	if m := libpodScopeRE.FindStringSubmatch(cgroupPath); m != nil {
+		return m[1], true
+	}
+	if m := libpodPathRE.FindStringSubmatch(cgroupPath); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// lxcResolver matches LXC containers managed as nested systemd slices.
+// Proxmox-style setups give the container a numeric CT ID and name both,
+// e.g. "/machine.slice/machine-lxc\x2d<id>\x2d<name>.scope"; vanilla
+// LXC-over-systemd units are named directly after the container, with no
+// numeric id segment, e.g. "/machine.slice/machine-lxc\x2dmycontainer.scope".
+// The numeric id is preferred when present since it's the stabler
+// identifier; otherwise the container name itself is returned as the id.
+type lxcResolver struct{}
+
+var (
+	lxcScopeWithIDRE = regexp.MustCompile(`machine-lxc\\x2d(\d+)\\x2d`)
+	lxcScopeRE       = regexp.MustCompile(`machine-lxc\\x2d([^.]+)\.scope$`)
+)
+
+func (lxcResolver) Match(cgroupPath string) (string, bool) {
+	if m := lxcScopeWithIDRE.FindStringSubmatch(cgroupPath); m != nil {
+		return m[1], true
+	}
+	if m := lxcScopeRE.FindStringSubmatch(cgroupPath); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// ContainerID returns the container ID running the process indicated by
+// the given PID. Returns the empty string if the process is not running
+// within a container recognized by a registered ContainerRuntimeResolver.
+func ContainerID(pid int32) string {
+	return FS().ContainerID(pid)
+}
+
+// ContainerID returns the container ID running the process indicated by
+// the given PID. Returns the empty string if the process is not running
+// within a container recognized by a registered ContainerRuntimeResolver.
+func (fs *FileSystem) ContainerID(pid int32) string {
+	id, _ := fs.ContainerInfo(pid)
+	return id
+}
+
+// ContainerInfo returns the container ID and the name of the container
+// runtime that created it for the process indicated by the given PID.
+// Both are the empty string if the process is not running within a
+// container recognized by a registered ContainerRuntimeResolver.
+func ContainerInfo(pid int32) (id string, runtime string) {
+	return FS().ContainerInfo(pid)
+}
+
+// ContainerInfo returns the container ID and the name of the container
+// runtime that created it for the process indicated by the given PID.
+// Both are the empty string if the process is not running within a
+// container recognized by a registered ContainerRuntimeResolver.
+func (fs *FileSystem) ContainerInfo(pid int32) (id string, runtime string) {
+	if fs.cache != nil {
+		if e := fs.cache.lookup(pid); e != nil {
+			e.mu.Lock()
+			id, runtime, known := e.containerID, e.containerRuntime, e.containerKnown
+			e.mu.Unlock()
+			if known {
+				fs.cache.recordHit()
+				return id, runtime
+			}
+		}
+		fs.cache.recordMiss()
+	}
+
+	cgroups, err := fs.Cgroups(pid)
+	if err != nil {
+		return "", ""
+	}
+
+	for _, pci := range cgroups {
+		if cid, crt, ok := resolveContainer(pci.Path); ok {
+			id, runtime = cid, crt
+			break
+		}
+	}
+
+	if fs.cache != nil {
+		e := fs.cache.entry(pid)
+		e.mu.Lock()
+		e.containerID = id
+		e.containerRuntime = runtime
+		e.containerKnown = true
+		e.mu.Unlock()
+	}
+
+	return id, runtime
+}
@@ -0,0 +1,36 @@
+package proc
+
+import "testing"
+
+func TestResolveContainer(t *testing.T) {
+	const id64 = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+	tests := []struct {
+		name        string
+		cgroupPath  string
+		wantID      string
+		wantRuntime string
+		wantOK      bool
+	}{
+		{"docker cgroupfs", "/docker/" + id64, id64, "docker", true},
+		{"docker systemd", "/system.slice/docker-" + id64 + ".scope", id64, "docker", true},
+		{"cri-containerd scope", "cri-containerd-" + id64 + ".scope", id64, "cri-containerd", true},
+		{"kubepods", "/kubepods.slice/kubepods-burstable.slice/pod123/" + id64, id64, "cri-containerd", true},
+		{"crio scope", "crio-" + id64 + ".scope", id64, "crio", true},
+		{"podman libpod scope", "/machine.slice/libpod-" + id64 + ".scope", id64, "podman", true},
+		{"podman libpod_parent", "/libpod_parent/" + id64, id64, "podman", true},
+		{"lxc with numeric ct id", "/machine.slice/machine-lxc\\x2d101\\x2dmycontainer.scope", "101", "lxc", true},
+		{"lxc named only", "/machine.slice/machine-lxc\\x2dmycontainer.scope", "mycontainer", "lxc", true},
+		{"unrecognized", "/user.slice/user-1000.slice", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, runtime, ok := resolveContainer(tt.cgroupPath)
+			if ok != tt.wantOK || id != tt.wantID || runtime != tt.wantRuntime {
+				t.Fatalf("resolveContainer(%q) = (%q, %q, %v), want (%q, %q, %v)",
+					tt.cgroupPath, id, runtime, ok, tt.wantID, tt.wantRuntime, tt.wantOK)
+			}
+		})
+	}
+}
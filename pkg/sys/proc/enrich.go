@@ -0,0 +1,71 @@
+package proc
+
+// CommandLineOrComm gets the full command-line arguments for the process
+// indicated by the given PID, falling back to its comm value (as reported
+// by /proc/[pid]/stat) when the real argv is unavailable, which happens
+// for processes that have already exited by the time /proc/[pid]/cmdline
+// is read. The returned truncated is true when the fallback was used, so
+// callers can tell a one-word comm from a genuine single-argument argv.
+func CommandLineOrComm(pid int32) (cmdline []string, truncated bool) {
+	return FS().CommandLineOrComm(pid)
+}
+
+// CommandLineOrComm gets the full command-line arguments for the process
+// indicated by the given PID, falling back to its comm value (as reported
+// by /proc/[pid]/stat) when the real argv is unavailable, which happens
+// for processes that have already exited by the time /proc/[pid]/cmdline
+// is read. The returned truncated is true when the fallback was used, so
+// callers can tell a one-word comm from a genuine single-argument argv.
+func (fs *FileSystem) CommandLineOrComm(pid int32) (cmdline []string, truncated bool) {
+	if cl := fs.CommandLine(pid); len(cl) > 0 {
+		return cl, false
+	}
+
+	ps, err := fs.Stat(pid)
+	if err != nil {
+		return nil, true
+	}
+
+	return []string{ps.Command()}, true
+}
+
+// EnrichFromExec records argv as the command line of pid, so that later
+// calls to CommandLine(pid) return it even once the process has exited and
+// /proc/[pid]/cmdline is gone. Sensors should call this from their
+// sched_process_exec / execve tracepoint handlers, which observe argv
+// directly and don't need to race /proc to capture it.
+//
+// EnrichFromExec is a no-op if the FileSystem isn't using a Cache.
+func EnrichFromExec(pid int32, argv []string) {
+	FS().EnrichFromExec(pid, argv)
+}
+
+// EnrichFromExec records argv as the command line of pid, so that later
+// calls to CommandLine(pid) return it even once the process has exited and
+// /proc/[pid]/cmdline is gone. Sensors should call this from their
+// sched_process_exec / execve tracepoint handlers, which observe argv
+// directly and don't need to race /proc to capture it.
+//
+// execve replaces the process image, which makes any ProcessStatus and
+// cgroup membership already cached for pid stale (comm in particular
+// changes to the name of the new image), so EnrichFromExec also drops
+// them; the next Stat or Cgroups call re-reads /proc instead of handing
+// back pre-exec data.
+//
+// EnrichFromExec is a no-op if fs isn't using a Cache.
+func (fs *FileSystem) EnrichFromExec(pid int32, argv []string) {
+	if fs.cache == nil {
+		return
+	}
+
+	e := fs.cache.entry(pid)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.cmdline = argv
+	e.cmdlineKnown = true
+	e.ps = nil
+	e.cgroups = nil
+	e.cgroupsKnown = false
+}
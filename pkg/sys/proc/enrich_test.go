@@ -0,0 +1,54 @@
+package proc
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestEnrichFromExecOverridesCommandLine(t *testing.T) {
+	fs := &FileSystem{MountPoint: "/proc", cache: NewCache(0)}
+	pid := int32(os.Getpid())
+
+	fs.EnrichFromExec(pid, []string{"fake", "argv"})
+
+	cmdline := fs.CommandLine(pid)
+	if !reflect.DeepEqual(cmdline, []string{"fake", "argv"}) {
+		t.Fatalf("CommandLine(%d) = %v, want enriched argv", pid, cmdline)
+	}
+}
+
+func TestEnrichFromExecIsNoopWithoutCache(t *testing.T) {
+	fs := &FileSystem{MountPoint: "/proc"}
+	pid := int32(os.Getpid())
+
+	// Must not panic: EnrichFromExec is documented as a no-op when fs
+	// isn't using a Cache.
+	fs.EnrichFromExec(pid, []string{"fake", "argv"})
+}
+
+func TestCommandLineOrCommFallsBackToComm(t *testing.T) {
+	fs := &FileSystem{MountPoint: "/proc"}
+
+	// kthreadd (PID 2) is the kernel thread parent: it has a valid
+	// /proc/[pid]/stat comm but, being a kernel thread rather than a
+	// userspace process, an empty /proc/[pid]/cmdline. That's exactly the
+	// shape CommandLineOrComm's fallback is meant to handle, so it's used
+	// here instead of a nonexistent PID, which would exercise only the
+	// (uninteresting) total-failure path, not the comm fallback itself.
+	const kthreaddPID = 2
+	if _, err := fs.Stat(kthreaddPID); err != nil {
+		t.Skipf("pid %d (kthreadd) not available: %v", kthreaddPID, err)
+	}
+	if cmdline := fs.CommandLine(kthreaddPID); len(cmdline) != 0 {
+		t.Skipf("pid %d has a non-empty cmdline %v, can't exercise the fallback", kthreaddPID, cmdline)
+	}
+
+	cmdline, truncated := fs.CommandLineOrComm(kthreaddPID)
+	if !truncated {
+		t.Fatalf("CommandLineOrComm(%d) truncated = false, want true", kthreaddPID)
+	}
+	if want := []string{"kthreadd"}; !reflect.DeepEqual(cmdline, want) {
+		t.Fatalf("CommandLineOrComm(%d) cmdline = %v, want %v", kthreaddPID, cmdline, want)
+	}
+}
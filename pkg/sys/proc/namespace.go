@@ -0,0 +1,122 @@
+package proc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// InNamespace returns a FileSystem that reads procfs as it appears from
+// inside the mount namespace of the process indicated by pid, rather than
+// the host's. This is necessary to correctly observe the cgroup layout,
+// command line, and other namespaced state of a process running inside a
+// container, which can differ from what the host's /proc reports (e.g.
+// when the container has unshared its own mounts or pivoted its root).
+//
+// Every Open/ReadFile performed through the returned FileSystem resolves
+// its path through the target's /proc/[pid]/root magic symlink (see
+// procPath), which the kernel itself resolves inside the target's mount
+// namespace and root filesystem. That's done entirely from the host's own
+// mount namespace: no setns(2)/CAP_SYS_ADMIN is required, and (unlike
+// setns) it keeps working when the target has also unshared its pid
+// namespace and mounted its own /proc, since the lookup never needs the
+// host thread to actually be inside that namespace.
+func (fs *FileSystem) InNamespace(pid int32) (*FileSystem, error) {
+	path := fmt.Sprintf("%s/%d/ns/mnt", fs.MountPoint, pid)
+	nsFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	nsFile.Close()
+
+	return &FileSystem{
+		MountPoint: fs.MountPoint,
+		nsPID:      pid,
+	}, nil
+}
+
+// procPath returns the real filesystem path that Open/ReadFile/ReadDir/
+// Readlink should use to read relativePath. For the host FileSystem this
+// is just relativePath joined onto MountPoint. For a FileSystem returned
+// by InNamespace, it's additionally routed through nsPID's
+// /proc/[pid]/root magic symlink, so the lookup resolves inside that
+// process's own root filesystem and mount namespace (as the kernel
+// resolves the symlink) rather than the host's.
+//
+// This is deliberately not done with chroot(2): chroot changes the whole
+// process's fs_struct (root and cwd are shared by every OS thread unless
+// a thread has unshared CLONE_FS), not just the calling thread's, so it
+// isn't safe to use from one goroutine while others may be reading procfs
+// through this package concurrently. Resolving through the magic symlink
+// has the same effect on lookups without that global side effect.
+//
+// A container with its own PID namespace also has its own /proc mount,
+// whose /proc/[pid] entries are numbered in that namespace rather than the
+// host's, so a host PID embedded in relativePath (e.g. "1234/stat") would
+// otherwise never match any entry in it. translatePID rewrites that
+// leading PID, if any, to the target namespace's local numbering before
+// the path is built.
+func (fs *FileSystem) procPath(relativePath string) string {
+	if fs.nsPID != 0 {
+		return filepath.Join("/proc", strconv.Itoa(int(fs.nsPID)), "root", fs.MountPoint, fs.translatePID(relativePath))
+	}
+
+	return filepath.Join(fs.MountPoint, relativePath)
+}
+
+// translatePID rewrites the leading PID segment of relativePath (as built
+// by e.g. fmt.Sprintf("%d/stat", pid)) from the host's numbering to its
+// equivalent in the target namespace, via the host's own
+// /proc/[pid]/status NSpid line, whose fields list a process's PID in
+// each nested PID namespace it belongs to, outermost first. relativePath
+// is returned unchanged if it doesn't start with a PID segment, or if the
+// translation can't be done (e.g. the process has already exited, or the
+// kernel predates NSpid).
+func (fs *FileSystem) translatePID(relativePath string) string {
+	pidStr, rest := relativePath, ""
+	if i := strings.IndexByte(relativePath, '/'); i >= 0 {
+		pidStr, rest = relativePath[:i], relativePath[i:]
+	}
+
+	hostPID, err := strconv.ParseInt(pidStr, 10, 32)
+	if err != nil {
+		return relativePath
+	}
+
+	nsPID, err := namespaceLocalPID(int32(hostPID))
+	if err != nil {
+		return relativePath
+	}
+
+	return strconv.Itoa(int(nsPID)) + rest
+}
+
+// namespaceLocalPID returns hostPID's PID as seen from the innermost PID
+// namespace it belongs to, read from the host's own
+// /proc/[hostPID]/status. For a process that hasn't unshared a PID
+// namespace, this is hostPID itself.
+func namespaceLocalPID(hostPID int32) (int32, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", hostPID))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "NSpid:" {
+			continue
+		}
+
+		nsPID, err := strconv.ParseInt(fields[len(fields)-1], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("couldn't parse NSpid field %q: %v", line, err)
+		}
+
+		return int32(nsPID), nil
+	}
+
+	return 0, fmt.Errorf("no NSpid field in /proc/%d/status", hostPID)
+}
@@ -0,0 +1,146 @@
+package proc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFileSystemProcPathResolvesThroughTargetNamespace verifies that a
+// FileSystem pointed at a pid's namespace actually reads that process's
+// mount namespace, not merely that InNamespace doesn't error. It spawns a
+// real child with its own mount namespace (via unshare(1)) that mounts a
+// tmpfs the host can't see, and checks that only the namespaced
+// FileSystem can read a file inside it.
+func TestFileSystemProcPathResolvesThroughTargetNamespace(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root to create a mount namespace")
+	}
+	if _, err := exec.LookPath("unshare"); err != nil {
+		t.Skip("unshare(1) not available")
+	}
+
+	cmd := exec.Command("unshare", "--mount", "--",
+		"sh", "-c", "mount -t tmpfs tmpfs /mnt && echo hello-marker >/mnt/marker && exec sleep 30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("couldn't create a mount namespace: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	pid := int32(cmd.Process.Pid)
+
+	// Give the child time to mount its tmpfs and write the marker before
+	// we race it from the host side.
+	var data []byte
+	var err error
+	for i := 0; i < 50; i++ {
+		data, err = (&FileSystem{MountPoint: "/mnt", nsPID: pid}).ReadFile("marker")
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("ReadFile through target namespace: %v", err)
+	}
+	if string(data) != "hello-marker\n" {
+		t.Fatalf("ReadFile through target namespace = %q, want %q", data, "hello-marker\n")
+	}
+
+	// The host's own /mnt shouldn't have picked up the child's tmpfs
+	// mount: a FileSystem without nsPID set must not see it.
+	if _, err := (&FileSystem{MountPoint: "/mnt"}).ReadFile("marker"); err == nil {
+		t.Fatal("ReadFile without a namespace unexpectedly saw the target's tmpfs mount")
+	}
+}
+
+// TestFileSystemProcPathResolvesWithOwnPIDAndProcMount exercises the case
+// the feature exists for: a container with its own mount *and* pid
+// namespace and its own /proc mount (as every real container runtime sets
+// up), rather than just a bare mount namespace. A FileSystem pointed at
+// such a container must still be able to look up a process by its
+// host-visible PID and read the right data back, even though that PID
+// has no entry at all in the container's own (differently-numbered)
+// /proc: procPath has to translate it to the container-local PID first.
+//
+// "unshare --pid --fork" doesn't itself join the new PID namespace: only
+// its *child* does (unshare(2) never affects the calling process, only
+// children it forks afterwards), so the process named by cmd.Process.Pid
+// is the outer, unnamespaced "unshare" process, and the one actually
+// running inside the new namespace is its child. The test has to resolve
+// that child's host PID (via /proc/[pid]/task/[pid]/children) and use
+// *that* as the PID under test, or it ends up unintentionally reading the
+// outer process's own (perfectly ordinary, host-numbered) /proc/[pid]/stat
+// and never exercising the translation at all.
+func TestFileSystemProcPathResolvesWithOwnPIDAndProcMount(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root to create mount and pid namespaces")
+	}
+	if _, err := exec.LookPath("unshare"); err != nil {
+		t.Skip("unshare(1) not available")
+	}
+
+	cmd := exec.Command("unshare", "--mount", "--pid", "--fork", "--mount-proc", "--",
+		"sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("couldn't create mount/pid namespaces: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	outerPID := int32(cmd.Process.Pid)
+	fs := &FileSystem{MountPoint: "/proc", nsPID: outerPID}
+
+	// Poll for the inner "sleep" process's host PID, and for procPath's
+	// translation of it to actually resolve to something with the right
+	// comm: both the child's appearance (clone) and its mount-proc setup
+	// race this goroutine.
+	var ps *ProcessStatus
+	var err error
+	for i := 0; i < 100; i++ {
+		var innerPID int32
+		if innerPID, err = childPID(outerPID); err == nil {
+			ps, err = fs.Stat(innerPID)
+			if err == nil && ps.Command() == "sleep" {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Stat through target's own /proc mount: %v", err)
+	}
+	if got := ps.Command(); got != "sleep" {
+		t.Fatalf("Stat through target's own /proc mount: Command() = %q, want %q", got, "sleep")
+	}
+}
+
+// childPID returns the host PID of the first (and in this test, only)
+// child of pid, by reading /proc/[pid]/task/[pid]/children.
+func childPID(pid int32) (int32, error) {
+	data, err := ReadFile(fmt.Sprintf("%d/task/%d/children", pid, pid))
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("pid %d has no children yet", pid)
+	}
+
+	child, err := strconv.ParseInt(fields[0], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse child pid %q: %v", fields[0], err)
+	}
+
+	return int32(child), nil
+}
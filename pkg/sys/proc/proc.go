@@ -21,8 +21,10 @@ var (
 	procFSOnce sync.Once
 	procFS     *FileSystem
 
-	// Boot ID taken from /proc/sys/kernel/random/boot_id
-	bootID string
+	// Boot ID taken from /proc/sys/kernel/random/boot_id, and the error
+	// (if any) encountered while reading it.
+	bootID    string
+	bootIDErr error
 
 	// "Once" control for getting the boot ID
 	bootIDOnce sync.Once
@@ -63,6 +65,7 @@ func FS() *FileSystem {
 
 		procFS = &FileSystem{
 			MountPoint: "/proc",
+			cache:      NewCache(DefaultCacheSize),
 		}
 	})
 
@@ -72,11 +75,35 @@ func FS() *FileSystem {
 // FileSystem represents data accessible through the proc pseudo-filesystem.
 type FileSystem struct {
 	MountPoint string
+
+	// nsPID is non-zero when this FileSystem was created by InNamespace,
+	// in which case every Open/ReadFile/ReadDir/Readlink resolves its path
+	// through that PID's /proc/[pid]/root (see procPath). No mount
+	// namespace is joined: setns(2) is never called.
+	nsPID int32
+
+	// cache holds previously-parsed process information, consulted by
+	// Stat, CommandLine, Cgroups, and ContainerInfo before they fall back
+	// to /proc. It is nil for FileSystems that aren't expected to be
+	// queried repeatedly for the same PIDs, such as those returned by
+	// InNamespace.
+	cache *Cache
+}
+
+// Cache returns the Cache backing fs, or nil if it doesn't have one.
+func (fs *FileSystem) Cache() *Cache {
+	return fs.cache
+}
+
+// SetCache installs c as fs's process cache, replacing any existing one.
+// Passing nil disables caching for fs.
+func (fs *FileSystem) SetCache(c *Cache) {
+	fs.cache = c
 }
 
 // Open opens the procfs file indicated by the given relative path.
 func (fs *FileSystem) Open(relativePath string) (*os.File, error) {
-	return os.Open(filepath.Join(fs.MountPoint, relativePath))
+	return os.Open(fs.procPath(relativePath))
 }
 
 // ReadFile returns the contents of the procfs file indicated by
@@ -88,7 +115,29 @@ func ReadFile(relativePath string) ([]byte, error) {
 // ReadFile returns the contents of the procfs file indicated by the
 // given relative path.
 func (fs *FileSystem) ReadFile(relativePath string) ([]byte, error) {
-	return ioutil.ReadFile(filepath.Join(fs.MountPoint, relativePath))
+	return ioutil.ReadFile(fs.procPath(relativePath))
+}
+
+// ReadDir returns the names of the entries in the procfs directory
+// indicated by the given relative path.
+func (fs *FileSystem) ReadDir(relativePath string) ([]string, error) {
+	entries, err := ioutil.ReadDir(fs.procPath(relativePath))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+
+	return names, nil
+}
+
+// Readlink returns the destination of the symbolic link indicated by the
+// given relative path.
+func (fs *FileSystem) Readlink(relativePath string) (string, error) {
+	return os.Readlink(fs.procPath(relativePath))
 }
 
 // CommandLine gets the full command-line arguments for the process
@@ -101,9 +150,25 @@ func CommandLine(pid int32) []string {
 // indicated by the given PID.
 func (fs *FileSystem) CommandLine(pid int32) []string {
 	//
-	// This misses the command-line arguments for short-lived processes,
-	// which is clearly not ideal.
+	// This misses the command-line arguments for short-lived processes
+	// whose /proc/[pid]/cmdline is already gone by the time it's read.
+	// EnrichFromExec lets callers that observe argv directly (e.g. an
+	// execve tracepoint) backfill it into the cache ahead of time; it is
+	// consulted here the same as a normal cache hit.
 	//
+	if fs.cache != nil {
+		if e := fs.cache.lookup(pid); e != nil {
+			e.mu.Lock()
+			cmdline, known := e.cmdline, e.cmdlineKnown
+			e.mu.Unlock()
+			if known {
+				fs.cache.recordHit()
+				return cmdline
+			}
+		}
+		fs.cache.recordMiss()
+	}
+
 	filename := fmt.Sprintf("%d/cmdline", pid)
 	cmdline, err := fs.ReadFile(filename)
 	if err != nil {
@@ -126,22 +191,43 @@ func (fs *FileSystem) CommandLine(pid int32) []string {
 		}
 	}
 
+	if fs.cache != nil {
+		e := fs.cache.entry(pid)
+		e.mu.Lock()
+		e.cmdline = commandLine
+		e.cmdlineKnown = true
+		e.mu.Unlock()
+	}
+
 	return commandLine
 }
 
 // Cgroups returns the cgroup membership of the process
 // indicated by the given PID.
-func Cgroups(pid int32) []Cgroup {
+func Cgroups(pid int32) ([]Cgroup, error) {
 	return FS().Cgroups(pid)
 }
 
 // Cgroups returns the cgroup membership of the process
 // indicated by the given PID.
-func (fs *FileSystem) Cgroups(pid int32) []Cgroup {
+func (fs *FileSystem) Cgroups(pid int32) ([]Cgroup, error) {
+	if fs.cache != nil {
+		if e := fs.cache.lookup(pid); e != nil {
+			e.mu.Lock()
+			cgroups, known := e.cgroups, e.cgroupsKnown
+			e.mu.Unlock()
+			if known {
+				fs.cache.recordHit()
+				return cgroups, nil
+			}
+		}
+		fs.cache.recordMiss()
+	}
+
 	filename := fmt.Sprintf("%d/cgroup", pid)
 	cgroup, err := fs.ReadFile(filename)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	var cgroups []Cgroup
@@ -149,10 +235,14 @@ func (fs *FileSystem) Cgroups(pid int32) []Cgroup {
 	scanner := bufio.NewScanner(bytes.NewReader(cgroup))
 	for scanner.Scan() {
 		t := scanner.Text()
-		parts := strings.Split(t, ":")
+		parts := strings.SplitN(t, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("malformed cgroup line: %q", t)
+		}
+
 		ID, err := strconv.Atoi(parts[0])
 		if err != nil {
-			glog.Fatalf("Couldn't parse cgroup line: %s", t)
+			return nil, fmt.Errorf("couldn't parse cgroup line %q: %v", t, err)
 		}
 
 		c := Cgroup{
@@ -164,7 +254,15 @@ func (fs *FileSystem) Cgroups(pid int32) []Cgroup {
 		cgroups = append(cgroups, c)
 	}
 
-	return cgroups
+	if fs.cache != nil {
+		e := fs.cache.entry(pid)
+		e.mu.Lock()
+		e.cgroups = cgroups
+		e.cgroupsKnown = true
+		e.mu.Unlock()
+	}
+
+	return cgroups, nil
 }
 
 // Cgroup describes the cgroup membership of a process
@@ -180,41 +278,18 @@ type Cgroup struct {
 	Path string
 }
 
-// ContainerID returns the container ID running the process
-// indicated by the given PID. Returns the empty string if the process
-// is not running within a container.
-func ContainerID(pid int32) string {
-	return FS().ContainerID(pid)
-}
-
-// ContainerID returns the container ID running the process
-// indicated by the given PID. Returns the empty string if the process
-// is not running within a container.
-func (fs *FileSystem) ContainerID(pid int32) string {
-	cgroups := fs.Cgroups(pid)
-
-	for _, pci := range cgroups {
-		if strings.HasPrefix(pci.Path, "/docker") {
-			pathParts := strings.Split(pci.Path, "/")
-			return pathParts[2]
-		}
-	}
-
-	return ""
-}
-
 // UniqueID returns a reproducible namespace-independent
 // unique identifier for the process indicated by the given PID.
-func UniqueID(pid int32) string {
+func UniqueID(pid int32) (string, error) {
 	return FS().UniqueID(pid)
 }
 
 // UniqueID returns a reproducible namespace-independent
 // unique identifier for the process indicated by the given PID.
-func (fs *FileSystem) UniqueID(pid int32) string {
-	ps := fs.Stat(pid)
-	if ps == nil {
-		return ""
+func (fs *FileSystem) UniqueID(pid int32) (string, error) {
+	ps, err := fs.Stat(pid)
+	if err != nil {
+		return "", err
 	}
 
 	return ps.UniqueID()
@@ -223,136 +298,268 @@ func (fs *FileSystem) UniqueID(pid int32) string {
 // Stat reads the given process's status and returns a ProcessStatus
 // with methods to parse and return information from that status as
 // needed.
-func Stat(pid int32) *ProcessStatus {
+func Stat(pid int32) (*ProcessStatus, error) {
 	return FS().Stat(pid)
 }
 
 // Stat reads the given process's status from the ProcFS receiver and
 // returns a ProcessStatus with methods to parse and return
 // information from that status as needed.
-func (fs *FileSystem) Stat(pid int32) *ProcessStatus {
+func (fs *FileSystem) Stat(pid int32) (*ProcessStatus, error) {
+	if fs.cache != nil {
+		if e := fs.cache.lookup(pid); e != nil {
+			e.mu.Lock()
+			ps := e.ps
+			e.mu.Unlock()
+			if ps != nil {
+				fs.cache.recordHit()
+				return ps, nil
+			}
+		}
+		fs.cache.recordMiss()
+	}
+
 	stat, err := fs.ReadFile(fmt.Sprintf("%d/stat", pid))
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	return &ProcessStatus{
-		statFields: strings.Fields(string(stat)),
+	fields, err := parseStatLine(string(stat))
+	if err != nil {
+		return nil, err
 	}
+
+	ps := &ProcessStatus{
+		statFields: fields,
+		pid:        pid,
+		fs:         fs,
+	}
+
+	if fs.cache != nil {
+		e := fs.cache.entry(pid)
+		e.mu.Lock()
+		e.ps = ps
+		e.mu.Unlock()
+		if uniqueID, err := ps.UniqueID(); err == nil {
+			fs.cache.indexUniqueID(e, uniqueID)
+		}
+	}
+
+	return ps, nil
+}
+
+// parseStatLine splits the contents of /proc/[pid]/stat into its
+// whitespace-delimited fields, field 0 being the pid and field 1 being the
+// comm value. comm is found by locating the kernel-delimited "(...)"
+// around it (the kernel-canonical approach), rather than by splitting the
+// whole line on whitespace, so a comm containing spaces or parentheses
+// (e.g. a process that execve'd itself under a crafted name) doesn't
+// misalign every field after it.
+func parseStatLine(line string) ([]string, error) {
+	line = strings.TrimRight(line, "\n")
+
+	open := strings.IndexByte(line, '(')
+	closeParen := strings.LastIndexByte(line, ')')
+	if open < 0 || closeParen < open {
+		return nil, fmt.Errorf("malformed stat line: %q", line)
+	}
+
+	rest := strings.Fields(line[closeParen+1:])
+	fields := make([]string, 0, 2+len(rest))
+	fields = append(fields, strings.TrimSpace(line[:open]))
+	fields = append(fields, line[open+1:closeParen])
+	fields = append(fields, rest...)
+
+	return fields, nil
 }
 
-// ProcessStatus represents process status available via /proc/[pid]/stat
+// ProcessStatus represents process status available via /proc/[pid]/stat.
+//
+// A ProcessStatus is safe for concurrent use: every lazily-parsed field
+// below is guarded by mu, since the same *ProcessStatus is handed out to
+// every concurrent caller that looks up its PID through a Cache.
 type ProcessStatus struct {
+	mu sync.Mutex
+
 	statFields []string
 	pid        int32
-	comm       string
 	ppid       int32
 	startTime  uint64
 	startStack uint64
 	uniqueID   string
+
+	// fs is the FileSystem that produced this ProcessStatus, used to
+	// lazily parse the other /proc/[pid]/* files backing the richer
+	// fields in status.go. It is nil for a ProcessStatus that wasn't
+	// created by FileSystem.Stat (e.g. in tests).
+	fs *FileSystem
+
+	// The remaining fields back the lazily-parsed accessors in status.go.
+	statusFieldsParsed bool
+	statusFieldsMap    map[string]string
+
+	uids      [4]uint32
+	uidsKnown bool
+	uidsErr   error
+
+	gids      [4]uint32
+	gidsKnown bool
+	gidsErr   error
+
+	groups      []uint32
+	groupsKnown bool
+	groupsErr   error
+
+	seccompMode  int
+	seccompKnown bool
+	seccompErr   error
+
+	noNewPrivs      bool
+	noNewPrivsKnown bool
+
+	namespaces      map[string]uint64
+	namespacesKnown bool
+
+	securityContext      string
+	securityContextKnown bool
 }
 
 // PID returns the PID of the process.
 func (ps *ProcessStatus) PID() int32 {
-	if ps.pid == 0 {
-		pid := ps.statFields[0]
-		i, err := strconv.ParseInt(pid, 0, 32)
-		if err != nil {
-			glog.Fatalf("Couldn't parse PID: %s", pid)
-		}
-
-		ps.pid = int32(i)
-	}
-
 	return ps.pid
 }
 
 // Command returns the command name associated with the process (this is
 // typically referred to as the comm value in Linux kernel interfaces).
 func (ps *ProcessStatus) Command() string {
-	if len(ps.comm) == 0 {
-		ps.comm = strings.Trim(ps.statFields[1], "()")
-	}
-
-	return ps.comm
+	return ps.statFields[1]
 }
 
 // ParentPID returns the PID of the parent of the process.
-func (ps *ProcessStatus) ParentPID() int32 {
-	if ps.ppid == 0 {
-		ppid := ps.statFields[3]
-		i, err := strconv.ParseInt(ppid, 0, 32)
-		if err != nil {
-			glog.Fatalf("Couldn't parse PPID: %s", ppid)
-		}
+func (ps *ProcessStatus) ParentPID() (int32, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
 
-		ps.ppid = int32(i)
+	if ps.ppid != 0 {
+		return ps.ppid, nil
 	}
 
-	return ps.ppid
+	if len(ps.statFields) <= 3 {
+		return 0, fmt.Errorf("stat line for pid %d is missing the ppid field", ps.pid)
+	}
+
+	ppid := ps.statFields[3]
+	i, err := strconv.ParseInt(ppid, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse ppid %q: %v", ppid, err)
+	}
+
+	ps.ppid = int32(i)
+	return ps.ppid, nil
 }
 
 // StartTime returns the time in jiffies (< 2.6) or clock ticks (>= 2.6)
 // after system boot when the process started.
-func (ps *ProcessStatus) StartTime() uint64 {
-	if ps.startTime == 0 {
-		st := ps.statFields[22-1]
-		i, err := strconv.ParseUint(st, 0, 64)
-		if err != nil {
-			glog.Fatalf("Couldn't parse starttime: %s", st)
-		}
+func (ps *ProcessStatus) StartTime() (uint64, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.startTime != 0 {
+		return ps.startTime, nil
+	}
 
-		ps.startTime = i
+	if len(ps.statFields) <= 22-1 {
+		return 0, fmt.Errorf("stat line for pid %d is missing the starttime field", ps.pid)
 	}
 
-	return ps.startTime
+	st := ps.statFields[22-1]
+	i, err := strconv.ParseUint(st, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse starttime %q: %v", st, err)
+	}
+
+	ps.startTime = i
+	return ps.startTime, nil
 }
 
 // StartStack returns the address of the start (i.e., bottom) of the stack.
-func (ps *ProcessStatus) StartStack() uint64 {
-	if ps.startStack == 0 {
-		ss := ps.statFields[28-1]
-		i, err := strconv.ParseUint(ss, 0, 64)
-		if err != nil {
-			glog.Fatalf("Couldn't parse startstack: %s", ss)
-		}
+func (ps *ProcessStatus) StartStack() (uint64, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.startStack != 0 {
+		return ps.startStack, nil
+	}
 
-		ps.startStack = i
+	if len(ps.statFields) <= 28-1 {
+		return 0, fmt.Errorf("stat line for pid %d is missing the startstack field", ps.pid)
+	}
+
+	ss := ps.statFields[28-1]
+	i, err := strconv.ParseUint(ss, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse startstack %q: %v", ss, err)
 	}
 
-	return ps.startStack
+	ps.startStack = i
+	return ps.startStack, nil
 }
 
 // UniqueID returns a reproducible unique identifier for the
 // process indicated by the given PID.
-func (ps *ProcessStatus) UniqueID() string {
-	if len(ps.uniqueID) == 0 {
-		// Hash the bootID, starting stack address, and start time to
-		// create a unique process identifier that has the same value
-		// regardless of the pid namespace (i.e. same value from
-		// within the container and from the underlying host).
-		h := sha256.New()
-
-		binary.Write(h, binary.LittleEndian, BootID())
-		binary.Write(h, binary.LittleEndian, ps.StartStack())
-		binary.Write(h, binary.LittleEndian, ps.StartTime())
-
-		ps.uniqueID = fmt.Sprintf("%x", h.Sum(nil))
+func (ps *ProcessStatus) UniqueID() (string, error) {
+	ps.mu.Lock()
+	uniqueID := ps.uniqueID
+	ps.mu.Unlock()
+	if len(uniqueID) != 0 {
+		return uniqueID, nil
 	}
 
-	return ps.uniqueID
+	// Hash the bootID, starting stack address, and start time to
+	// create a unique process identifier that has the same value
+	// regardless of the pid namespace (i.e. same value from
+	// within the container and from the underlying host). StartStack
+	// and StartTime take their own lock, so it isn't held across them.
+	bootID, err := BootID()
+	if err != nil {
+		return "", err
+	}
+
+	startStack, err := ps.StartStack()
+	if err != nil {
+		return "", err
+	}
+
+	startTime, err := ps.StartTime()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+
+	h.Write([]byte(bootID))
+	binary.Write(h, binary.LittleEndian, startStack)
+	binary.Write(h, binary.LittleEndian, startTime)
+
+	ps.mu.Lock()
+	ps.uniqueID = fmt.Sprintf("%x", h.Sum(nil))
+	uniqueID = ps.uniqueID
+	ps.mu.Unlock()
+
+	return uniqueID, nil
 }
 
 // BootID gets the host system boot identifier
-func BootID() string {
+func BootID() (string, error) {
 	bootIDOnce.Do(func() {
 		data, err := ReadFile("/sys/kernel/random/boot_id")
 		if err != nil {
-			panic(err)
+			bootIDErr = err
+			return
 		}
 
 		bootID = strings.TrimSpace(string(data))
 	})
 
-	return bootID
-}
\ No newline at end of file
+	return bootID, bootIDErr
+}
@@ -0,0 +1,50 @@
+package proc
+
+import "testing"
+
+func TestParseStatLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{
+			name: "ordinary comm",
+			line: "1234 (bash) S 1 1234 1234 0 -1 4194304 ...\n",
+			want: []string{"1234", "bash", "S", "1", "1234", "1234", "0", "-1", "4194304", "..."},
+		},
+		{
+			name: "comm containing a closing paren",
+			line: "1234 (evil)name) S 1 1234 1234\n",
+			want: []string{"1234", "evil)name", "S", "1", "1234", "1234"},
+		},
+		{
+			name: "comm containing spaces",
+			line: "1234 (my cmd) S 1 1234 1234\n",
+			want: []string{"1234", "my cmd", "S", "1", "1234", "1234"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStatLine(tt.line)
+			if err != nil {
+				t.Fatalf("parseStatLine(%q): %v", tt.line, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseStatLine(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("parseStatLine(%q)[%d] = %q, want %q", tt.line, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseStatLineMalformed(t *testing.T) {
+	if _, err := parseStatLine("no parens here\n"); err == nil {
+		t.Fatal("parseStatLine with no parens: want error, got nil")
+	}
+}
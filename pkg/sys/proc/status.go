@@ -0,0 +1,337 @@
+package proc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// capabilityNames is the kernel's capability bit -> name table (see
+// capability(7)), indexed by bit number.
+var capabilityNames = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_DAC_READ_SEARCH",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETPCAP",
+	"CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN",
+	"CAP_NET_RAW",
+	"CAP_IPC_LOCK",
+	"CAP_IPC_OWNER",
+	"CAP_SYS_MODULE",
+	"CAP_SYS_RAWIO",
+	"CAP_SYS_CHROOT",
+	"CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT",
+	"CAP_SYS_ADMIN",
+	"CAP_SYS_BOOT",
+	"CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME",
+	"CAP_SYS_TTY_CONFIG",
+	"CAP_MKNOD",
+	"CAP_LEASE",
+	"CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL",
+	"CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE",
+	"CAP_MAC_ADMIN",
+	"CAP_SYSLOG",
+	"CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND",
+	"CAP_AUDIT_READ",
+	"CAP_PERFMON",
+	"CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// CapabilityNames decodes a capability bitmask, as returned by
+// CapEffective/CapPermitted/CapInheritable/CapBounding/CapAmbient, into the
+// names of the bits that are set.
+func CapabilityNames(mask uint64) []string {
+	var names []string
+
+	for bit := 0; bit < 64; bit++ {
+		if mask&(1<<uint(bit)) == 0 {
+			continue
+		}
+
+		if bit < len(capabilityNames) {
+			names = append(names, capabilityNames[bit])
+		} else {
+			names = append(names, fmt.Sprintf("CAP_UNKNOWN_%d", bit))
+		}
+	}
+
+	return names
+}
+
+// statusFields lazily reads and parses /proc/[pid]/status into a map of
+// field name to raw (still tab-separated) value. Callers must hold ps.mu.
+func (ps *ProcessStatus) statusFields() map[string]string {
+	if ps.statusFieldsParsed {
+		return ps.statusFieldsMap
+	}
+	ps.statusFieldsParsed = true
+
+	if ps.fs == nil {
+		return nil
+	}
+
+	data, err := ps.fs.ReadFile(fmt.Sprintf("%d/status", ps.PID()))
+	if err != nil {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields[parts[0]] = strings.TrimSpace(parts[1])
+	}
+
+	ps.statusFieldsMap = fields
+	return fields
+}
+
+// parseIDQuad parses a /proc/[pid]/status Uid: or Gid: line, which lists
+// the real, effective, saved-set, and filesystem IDs in that order.
+func parseIDQuad(value string) ([4]uint32, error) {
+	var ids [4]uint32
+
+	fields := strings.Fields(value)
+	for i := 0; i < len(ids) && i < len(fields); i++ {
+		n, err := strconv.ParseUint(fields[i], 10, 32)
+		if err != nil {
+			return ids, fmt.Errorf("couldn't parse id field %q: %v", fields[i], err)
+		}
+		ids[i] = uint32(n)
+	}
+
+	return ids, nil
+}
+
+// UIDs returns the real, effective, saved-set, and filesystem UIDs of the
+// process, in that order.
+func (ps *ProcessStatus) UIDs() ([4]uint32, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if !ps.uidsKnown {
+		ps.uidsKnown = true
+
+		if v, ok := ps.statusFields()["Uid"]; ok {
+			ps.uids, ps.uidsErr = parseIDQuad(v)
+		}
+	}
+
+	return ps.uids, ps.uidsErr
+}
+
+// GIDs returns the real, effective, saved-set, and filesystem GIDs of the
+// process, in that order.
+func (ps *ProcessStatus) GIDs() ([4]uint32, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if !ps.gidsKnown {
+		ps.gidsKnown = true
+
+		if v, ok := ps.statusFields()["Gid"]; ok {
+			ps.gids, ps.gidsErr = parseIDQuad(v)
+		}
+	}
+
+	return ps.gids, ps.gidsErr
+}
+
+// Groups returns the supplementary group IDs of the process.
+func (ps *ProcessStatus) Groups() ([]uint32, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if !ps.groupsKnown {
+		ps.groupsKnown = true
+
+		v, ok := ps.statusFields()["Groups"]
+		if !ok {
+			return nil, nil
+		}
+
+		for _, f := range strings.Fields(v) {
+			n, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				ps.groups = nil
+				ps.groupsErr = fmt.Errorf("couldn't parse group %q: %v", f, err)
+				break
+			}
+			ps.groups = append(ps.groups, uint32(n))
+		}
+	}
+
+	return ps.groups, ps.groupsErr
+}
+
+func (ps *ProcessStatus) capMask(field string) (uint64, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	v, ok := ps.statusFields()[field]
+	if !ok {
+		return 0, nil
+	}
+
+	mask, err := strconv.ParseUint(v, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse %s capability mask %q: %v", field, v, err)
+	}
+
+	return mask, nil
+}
+
+// CapEffective returns the process's effective capability set.
+func (ps *ProcessStatus) CapEffective() (uint64, error) {
+	return ps.capMask("CapEff")
+}
+
+// CapPermitted returns the process's permitted capability set.
+func (ps *ProcessStatus) CapPermitted() (uint64, error) {
+	return ps.capMask("CapPrm")
+}
+
+// CapInheritable returns the process's inheritable capability set.
+func (ps *ProcessStatus) CapInheritable() (uint64, error) {
+	return ps.capMask("CapInh")
+}
+
+// CapBounding returns the process's capability bounding set.
+func (ps *ProcessStatus) CapBounding() (uint64, error) {
+	return ps.capMask("CapBnd")
+}
+
+// CapAmbient returns the process's ambient capability set.
+func (ps *ProcessStatus) CapAmbient() (uint64, error) {
+	return ps.capMask("CapAmb")
+}
+
+// SeccompMode returns the process's seccomp mode: 0 (SECCOMP_MODE_DISABLED)
+// if it isn't using seccomp, 1 (SECCOMP_MODE_STRICT) or 2
+// (SECCOMP_MODE_FILTER) otherwise.
+func (ps *ProcessStatus) SeccompMode() (int, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if !ps.seccompKnown {
+		ps.seccompKnown = true
+
+		if v, ok := ps.statusFields()["Seccomp"]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				ps.seccompErr = fmt.Errorf("couldn't parse Seccomp field %q: %v", v, err)
+			} else {
+				ps.seccompMode = n
+			}
+		}
+	}
+
+	return ps.seccompMode, ps.seccompErr
+}
+
+// NoNewPrivs returns whether the process has the no_new_privs bit set,
+// meaning it (and its descendants) can never gain privileges via execve(2).
+func (ps *ProcessStatus) NoNewPrivs() bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if !ps.noNewPrivsKnown {
+		ps.noNewPrivsKnown = true
+
+		if v, ok := ps.statusFields()["NoNewPrivs"]; ok {
+			ps.noNewPrivs = strings.TrimSpace(v) == "1"
+		}
+	}
+
+	return ps.noNewPrivs
+}
+
+// Namespaces returns the inode number of each namespace the process
+// belongs to, keyed by namespace type (e.g. "mnt", "pid", "net", "uts",
+// "ipc", "user", "cgroup"), as found under /proc/[pid]/ns.
+func (ps *ProcessStatus) Namespaces() map[string]uint64 {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.namespacesKnown {
+		return ps.namespaces
+	}
+	ps.namespacesKnown = true
+
+	if ps.fs == nil {
+		return nil
+	}
+
+	dir := fmt.Sprintf("%d/ns", ps.PID())
+	entries, err := ps.fs.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	namespaces := make(map[string]uint64, len(entries))
+	for _, name := range entries {
+		target, err := ps.fs.Readlink(fmt.Sprintf("%s/%s", dir, name))
+		if err != nil {
+			continue
+		}
+
+		// Namespace symlinks point at e.g. "mnt:[4026531840]".
+		open := strings.IndexByte(target, '[')
+		close := strings.IndexByte(target, ']')
+		if open < 0 || close <= open {
+			continue
+		}
+
+		inode, err := strconv.ParseUint(target[open+1:close], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		namespaces[name] = inode
+	}
+
+	ps.namespaces = namespaces
+	return namespaces
+}
+
+// SecurityContext returns the process's LSM security context (e.g. the
+// SELinux or AppArmor label), as reported by /proc/[pid]/attr/current.
+// It returns the empty string if no LSM exposes one.
+func (ps *ProcessStatus) SecurityContext() string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.securityContextKnown {
+		return ps.securityContext
+	}
+	ps.securityContextKnown = true
+
+	if ps.fs == nil {
+		return ""
+	}
+
+	data, err := ps.fs.ReadFile(fmt.Sprintf("%d/attr/current", ps.PID()))
+	if err != nil {
+		return ""
+	}
+
+	ps.securityContext = strings.TrimSpace(string(data))
+	return ps.securityContext
+}
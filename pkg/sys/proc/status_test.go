@@ -0,0 +1,45 @@
+package proc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCapabilityNames(t *testing.T) {
+	tests := []struct {
+		name string
+		mask uint64
+		want []string
+	}{
+		{"none", 0, nil},
+		{"chown only", 1 << 0, []string{"CAP_CHOWN"}},
+		{"sys_admin and net_raw", (1 << 21) | (1 << 13), []string{"CAP_NET_RAW", "CAP_SYS_ADMIN"}},
+		{"unknown high bit", 1 << 63, []string{"CAP_UNKNOWN_63"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CapabilityNames(tt.mask)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("CapabilityNames(%#x) = %v, want %v", tt.mask, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIDQuad(t *testing.T) {
+	got, err := parseIDQuad("1000\t1000\t1000\t1000")
+	if err != nil {
+		t.Fatalf("parseIDQuad: %v", err)
+	}
+	want := [4]uint32{1000, 1000, 1000, 1000}
+	if got != want {
+		t.Fatalf("parseIDQuad = %v, want %v", got, want)
+	}
+}
+
+func TestParseIDQuadMalformed(t *testing.T) {
+	if _, err := parseIDQuad("not-a-number\t0\t0\t0"); err == nil {
+		t.Fatal("parseIDQuad with a non-numeric field: want error, got nil")
+	}
+}